@@ -0,0 +1,139 @@
+package api
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"time"
+)
+
+// caCertValidityPeriod and serverCertValidityPeriod bound the lifetime of the self-signed CA and server
+// certificate this mock generates at startup. Both are regenerated on every process start, so there's no
+// need for either to outlive it.
+const (
+	caCertValidityPeriod     = 24 * time.Hour
+	serverCertValidityPeriod = 24 * time.Hour
+)
+
+// generateSelfSignedCA generates a self-signed CA certificate and its signing key.
+func generateSelfSignedCA() (certPEM []byte, certDER []byte, key *rsa.PrivateKey, err error) {
+	key, err = rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("generate CA key: %w", err)
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("generate CA serial number: %w", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "kubemock CA"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(caCertValidityPeriod),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	certDER, err = x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("create CA certificate: %w", err)
+	}
+
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER})
+	return certPEM, certDER, key, nil
+}
+
+// generateServerCertificate generates a server certificate, signed by the given CA, covering 127.0.0.1,
+// localhost, and kubernetes.default.svc so real kube clients (and Vault's kubernetes auth backend, when
+// kubernetes_ca_cert is set) accept it.
+func generateServerCertificate(caCertDER []byte, caKey *rsa.PrivateKey) (tls.Certificate, error) {
+	caCert, err := x509.ParseCertificate(caCertDER)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("parse CA certificate: %w", err)
+	}
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("generate server key: %w", err)
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("generate server serial number: %w", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: "kubernetes.default.svc"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(serverCertValidityPeriod),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		DNSNames:     []string{"localhost", "kubernetes.default.svc"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+
+	certDER, err := x509.CreateCertificate(rand.Reader, template, caCert, &key.PublicKey, caKey)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("create server certificate: %w", err)
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+
+	tlsCert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("build tls certificate: %w", err)
+	}
+
+	return tlsCert, nil
+}
+
+// CAHandler serves the PEM-encoded CA certificate this mock's server certificate was signed by, so tests
+// can configure their own kube clients (or Vault's kubernetes_ca_cert) to trust it instead of disabling
+// TLS verification.
+func (s *KubeHandler) CAHandler(w http.ResponseWriter, r *http.Request) {
+	s.logger.Debug("Kube auth server received CA bundle request")
+
+	if r.Method != http.MethodGet {
+		s.writeResponse(w, http.StatusNotImplemented, map[string]any{
+			"success": false,
+			"error":   fmt.Sprintf("CA handler expects GET but got %q", r.Method),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-pem-file")
+	w.WriteHeader(http.StatusOK)
+	_, err := w.Write(s.caCertPEM)
+	if err != nil {
+		s.logger.WithError(err).Error("Could not write CA bundle response")
+	}
+}
+
+// CertPool returns an *x509.CertPool containing the CA this handler's server certificate was signed by,
+// for tests that configure a Go http.Client or kube client to trust this mock without disabling TLS
+// verification.
+func (s *KubeHandler) CertPool() (*x509.CertPool, error) {
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(s.caCertPEM) {
+		return nil, fmt.Errorf("append CA certificate to pool")
+	}
+	return pool, nil
+}
+
+// ServerTLSCertificate returns the TLS certificate this handler generated for 127.0.0.1, localhost, and
+// kubernetes.default.svc, ready to be set on an http.Server's TLSConfig for use with ServeTLS.
+func (s *KubeHandler) ServerTLSCertificate() tls.Certificate {
+	return s.serverCert
+}
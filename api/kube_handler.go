@@ -3,41 +3,167 @@ package api
 import (
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"math/big"
 	"net/http"
 	"slices"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/sirupsen/logrus"
 )
 
+// defaultServiceAccountAudience is used whenever a caller does not request a specific audience, mirroring the
+// default kube-apiserver audience a cluster's service account issuer signs for.
+const defaultServiceAccountAudience = "https://kubernetes.default.svc"
+
+// defaultTokenExpirationSeconds is used whenever a TokenRequest does not specify spec.expirationSeconds.
+const defaultTokenExpirationSeconds = int64(3600)
+
+// defaultIssuer is used whenever NewKubeHandler is not given an explicit issuer URL.
+const defaultIssuer = "https://kubernetes.default.svc"
+
+// serviceAccountUIDClaim is the projected service account token claim authenticateBearerToken resolves a
+// bearer token's service account registration by.
+const serviceAccountUIDClaim = "kubernetes.io/serviceaccount/service-account.uid"
+
+// forceInvalid* are the values accepted by a token mint request's "forceInvalid" field, a test knob that
+// mints a deliberately broken token so callers can exercise Vault's error-handling branches.
+const (
+	forceInvalidExpired       = "expired"
+	forceInvalidBadSignature  = "bad_signature"
+	forceInvalidWrongAudience = "wrong_audience"
+)
+
 type serviceAccountInfo struct {
-	UID       string `json:"uid"`
-	Name      string `json:"name"`
-	Namespace string `json:"namespace"`
+	UID       string              `json:"uid"`
+	Name      string              `json:"name"`
+	Namespace string              `json:"namespace"`
+	Audiences []string            `json:"audiences,omitempty"`
+	Groups    []string            `json:"groups,omitempty"`
+	Extra     map[string][]string `json:"extra,omitempty"`
 }
 
+// registerServiceAccountRequest is the body accepted by RegisterServiceAccountHandler.
+type registerServiceAccountRequest struct {
+	serviceAccountInfo
+	// ForceInvalid is a testing-only knob: when set to one of the forceInvalid* values, the minted token is
+	// deliberately broken so callers can exercise Vault's error-handling branches.
+	ForceInvalid string `json:"forceInvalid,omitempty"`
+}
+
+// loginRequest mirrors the parts of authentication.k8s.io/v1 TokenReview that LoginHandler cares about.
+// Spec is echoed back verbatim in the response, as kube-apiserver does.
 type loginRequest struct {
 	Spec struct {
-		Token string `json:"token"`
+		Token     string   `json:"token"`
+		Audiences []string `json:"audiences,omitempty"`
+	} `json:"spec"`
+}
+
+// tokenRequest mirrors the authentication.k8s.io/v1 TokenRequest object accepted by the
+// serviceaccounts/{name}/token subresource.
+type tokenRequest struct {
+	Spec struct {
+		Audiences         []string `json:"audiences,omitempty"`
+		ExpirationSeconds *int64   `json:"expirationSeconds,omitempty"`
+		BoundObjectRef    *struct {
+			Kind string `json:"kind"`
+			Name string `json:"name"`
+			UID  string `json:"uid"`
+		} `json:"boundObjectRef,omitempty"`
 	} `json:"spec"`
+	// ForceInvalid is a testing-only knob: when set to one of the forceInvalid* values, the minted token is
+	// deliberately broken so callers can exercise Vault's error-handling branches.
+	ForceInvalid string `json:"forceInvalid,omitempty"`
+}
+
+// tokenRequestStatus mirrors authentication.k8s.io/v1 TokenRequestStatus.
+type tokenRequestStatus struct {
+	Token               string `json:"token"`
+	ExpirationTimestamp string `json:"expirationTimestamp"`
+}
+
+// ServerOptions configures NewKubeHandler.
+type ServerOptions struct {
+	// Logger receives debug/info/error logs from the mock's handlers.
+	Logger *logrus.Logger
+	// Issuer is the issuer URL embedded in minted JWTs and the OIDC discovery document. Defaults to
+	// defaultIssuer when empty.
+	Issuer string
 }
 
 // KubeHandler handles kube auth requests.
 type KubeHandler struct {
-	logger                  *logrus.Logger
-	jwtToServiceAccountInfo map[string]serviceAccountInfo
-	mu                      sync.RWMutex
+	logger     *logrus.Logger
+	issuer     string
+	signingKey *rsa.PrivateKey
+	keyID      string
+	// serviceAccountsByUID is keyed by service account UID rather than by the raw token string, since a
+	// bearer token is now authenticated by parsing and verifying the jwt itself; see authenticateBearerToken.
+	serviceAccountsByUID map[string]serviceAccountInfo
+	mu                   sync.RWMutex
+
+	rbacRules []rbacRule
+	rbacMu    sync.RWMutex
+
+	caCertPEM  []byte
+	serverCert tls.Certificate
+
+	resourceStores map[string]*genericResourceStore
 }
 
-// NewKubeHandler creates and returns a new kube handler.
-func NewKubeHandler(logger *logrus.Logger) *KubeHandler {
-	return &KubeHandler{
-		logger:                  logger,
-		jwtToServiceAccountInfo: make(map[string]serviceAccountInfo),
+// NewKubeHandler creates and returns a new kube handler. A single RSA signing key is generated for the
+// lifetime of the handler so that tokens it mints can be verified against the JWKS served by JWKSHandler,
+// and a self-signed CA plus a server certificate covering 127.0.0.1, localhost, and
+// kubernetes.default.svc are generated so the mock can be served over TLS. If opts.Issuer is empty,
+// defaultIssuer is used.
+func NewKubeHandler(opts ServerOptions) (*KubeHandler, error) {
+	issuer := opts.Issuer
+	if issuer == "" {
+		issuer = defaultIssuer
+	}
+
+	signingKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, fmt.Errorf("generate signing key: %w", err)
+	}
+
+	keyID, err := newUID()
+	if err != nil {
+		return nil, fmt.Errorf("generate key id: %w", err)
+	}
+
+	caCertPEM, caCertDER, caKey, err := generateSelfSignedCA()
+	if err != nil {
+		return nil, fmt.Errorf("generate CA: %w", err)
 	}
+
+	serverCert, err := generateServerCertificate(caCertDER, caKey)
+	if err != nil {
+		return nil, fmt.Errorf("generate server certificate: %w", err)
+	}
+
+	return &KubeHandler{
+		logger:               opts.Logger,
+		issuer:               issuer,
+		signingKey:           signingKey,
+		keyID:                keyID,
+		serviceAccountsByUID: make(map[string]serviceAccountInfo),
+		caCertPEM:            caCertPEM,
+		serverCert:           serverCert,
+		resourceStores: map[string]*genericResourceStore{
+			"secrets":         newGenericResourceStore(),
+			"configmaps":      newGenericResourceStore(),
+			"serviceaccounts": newGenericResourceStore(),
+		},
+	}, nil
 }
 
 // UnimplementedHandler handles any unimplemented request.
@@ -93,19 +219,30 @@ func (s *KubeHandler) ResetHandler(w http.ResponseWriter, r *http.Request) {
 
 	uids, _ := req["uids"].([]string)
 	if len(uids) == 0 {
-		s.jwtToServiceAccountInfo = make(map[string]serviceAccountInfo)
+		s.serviceAccountsByUID = make(map[string]serviceAccountInfo)
+
+		s.rbacMu.Lock()
+		s.rbacRules = nil
+		s.rbacMu.Unlock()
+
+		for _, store := range s.resourceStores {
+			store.reset()
+		}
+
 		s.writeResponse(w, http.StatusOK, nil)
 		return
 	}
 
 	for uid := range slices.Values(uids) {
-		delete(s.jwtToServiceAccountInfo, uid)
+		delete(s.serviceAccountsByUID, uid)
 	}
 
 	s.writeResponse(w, http.StatusOK, nil)
 }
 
 // RegisterServiceAccountHandler handles service account registration requests made directly by unit tests.
+// Tests may attach custom groups and extra to the service account before registering it; LoginHandler
+// echoes both back in a TokenReview's status.user once the minted token is presented.
 func (s *KubeHandler) RegisterServiceAccountHandler(w http.ResponseWriter, r *http.Request) {
 	s.logger.Debug("Kube auth server received service account registration request")
 
@@ -117,8 +254,8 @@ func (s *KubeHandler) RegisterServiceAccountHandler(w http.ResponseWriter, r *ht
 		return
 	}
 
-	var sa serviceAccountInfo
-	err := json.NewDecoder(r.Body).Decode(&sa)
+	var req registerServiceAccountRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
 		s.logger.WithError(err).Error("Could not decode service account registration request")
 		s.writeResponse(w, http.StatusBadRequest, map[string]any{
@@ -128,7 +265,29 @@ func (s *KubeHandler) RegisterServiceAccountHandler(w http.ResponseWriter, r *ht
 		return
 	}
 
-	jwtToken, err := generateKubeJWT(sa.Name, sa.Namespace, sa.UID)
+	sa := req.serviceAccountInfo
+	if len(sa.Audiences) == 0 {
+		sa.Audiences = []string{defaultServiceAccountAudience}
+	}
+	if sa.UID == "" {
+		sa.UID, err = newUID()
+		if err != nil {
+			s.logger.WithError(err).Error("Could not generate service account uid")
+			s.writeResponse(w, http.StatusInternalServerError, map[string]any{
+				"success": false,
+				"error":   fmt.Sprintf("generate service account uid: %v", err),
+			})
+			return
+		}
+	}
+
+	jwtToken, _, err := s.generateKubeJWT(kubeJWTClaims{
+		Name:         sa.Name,
+		Namespace:    sa.Namespace,
+		UID:          sa.UID,
+		Audiences:    sa.Audiences,
+		ForceInvalid: req.ForceInvalid,
+	})
 	if err != nil {
 		s.logger.WithError(err).WithField("service_account", sa).Error("Could not generate jwt token")
 		s.writeResponse(w, http.StatusInternalServerError, map[string]any{
@@ -141,15 +300,141 @@ func (s *KubeHandler) RegisterServiceAccountHandler(w http.ResponseWriter, r *ht
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	s.jwtToServiceAccountInfo[jwtToken] = sa
+	s.serviceAccountsByUID[sa.UID] = sa
 	s.writeResponse(w, http.StatusOK, map[string]any{
 		"success": true,
 		"jwt":     jwtToken,
 	})
 }
 
+// TokenHandler handles TokenRequest requests for the serviceaccounts/{name}/token subresource, minting a
+// fresh, audience-bound, expiring service account token the way kube-apiserver would.
+func (s *KubeHandler) TokenHandler(w http.ResponseWriter, r *http.Request) {
+	s.logger.Debug("Kube auth server received token request")
+
+	if r.Method != http.MethodPost {
+		s.writeResponse(w, http.StatusNotImplemented, map[string]any{
+			"success": false,
+			"error":   fmt.Sprintf("token request handler expects POST but got %q", r.Method),
+		})
+		return
+	}
+
+	namespace := r.PathValue("namespace")
+	name := r.PathValue("name")
+
+	var req tokenRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		s.logger.WithError(err).Error("Could not decode token request")
+		s.writeResponse(w, http.StatusBadRequest, map[string]any{
+			"success": false,
+			"error":   fmt.Sprintf("invalid token request: %v", err),
+		})
+		return
+	}
+
+	uid, err := newUID()
+	if err != nil {
+		s.logger.WithError(err).Error("Could not generate service account uid")
+		s.writeResponse(w, http.StatusInternalServerError, map[string]any{
+			"success": false,
+			"error":   fmt.Sprintf("generate service account uid: %v", err),
+		})
+		return
+	}
+
+	var expirationSeconds int64
+	if req.Spec.ExpirationSeconds != nil {
+		expirationSeconds = *req.Spec.ExpirationSeconds
+	}
+
+	audiences := req.Spec.Audiences
+	if len(audiences) == 0 {
+		audiences = []string{defaultServiceAccountAudience}
+	}
+
+	claims := kubeJWTClaims{
+		Name:              name,
+		Namespace:         namespace,
+		UID:               uid,
+		Audiences:         audiences,
+		ExpirationSeconds: expirationSeconds,
+		ForceInvalid:      req.ForceInvalid,
+	}
+	if req.Spec.BoundObjectRef != nil {
+		claims.BoundObjectRef = &kubeJWTBoundObjectRef{
+			Kind: req.Spec.BoundObjectRef.Kind,
+			Name: req.Spec.BoundObjectRef.Name,
+			UID:  req.Spec.BoundObjectRef.UID,
+		}
+	}
+
+	jwtToken, expiresAt, err := s.generateKubeJWT(claims)
+	if err != nil {
+		s.logger.WithError(err).Error("Could not generate jwt token")
+		s.writeResponse(w, http.StatusInternalServerError, map[string]any{
+			"success": false,
+			"error":   fmt.Sprintf("generate jwt token: %v", err),
+		})
+		return
+	}
+
+	sa := serviceAccountInfo{
+		UID:       uid,
+		Name:      name,
+		Namespace: namespace,
+		Audiences: audiences,
+	}
+
+	s.mu.Lock()
+	s.serviceAccountsByUID[uid] = sa
+	s.mu.Unlock()
+
+	s.writeResponse(w, http.StatusOK, map[string]any{
+		"apiVersion": "authentication.k8s.io/v1",
+		"kind":       "TokenRequest",
+		"status": tokenRequestStatus{
+			Token:               jwtToken,
+			ExpirationTimestamp: expiresAt.UTC().Format(time.RFC3339),
+		},
+	})
+
+	s.logger.Debug("Successfully handled token request")
+}
+
+// authenticateBearerToken parses and verifies token as a jwt minted by generateKubeJWT, checking its
+// signature, signing method, issuer, expiry, and that its aud claim includes the cluster's default
+// audience, then resolves it to the service account registered under the uid carried in its
+// kubernetes.io/serviceaccount/service-account.uid claim. This is the same baseline audience check
+// kube-apiserver always applies regardless of the caller's requested audiences; LoginHandler layers its
+// own intersection against spec.audiences on top when the caller asks for a narrower set.
+func (s *KubeHandler) authenticateBearerToken(token string) (serviceAccountInfo, jwt.MapClaims, error) {
+	var claims jwt.MapClaims
+	_, err := jwt.ParseWithClaims(token, &claims, func(*jwt.Token) (any, error) {
+		return &s.signingKey.PublicKey, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg()}), jwt.WithIssuer(s.issuer),
+		jwt.WithAudience(defaultServiceAccountAudience))
+	if err != nil {
+		return serviceAccountInfo{}, nil, fmt.Errorf("token lookup failed: %w", err)
+	}
+
+	uid, _ := claims[serviceAccountUIDClaim].(string)
+
+	s.mu.RLock()
+	sa, known := s.serviceAccountsByUID[uid]
+	s.mu.RUnlock()
+	if !known {
+		return serviceAccountInfo{}, nil, fmt.Errorf("token lookup failed: unknown service account uid %q", uid)
+	}
+
+	return sa, claims, nil
+}
+
 // LoginHandler handles kube auth login requests made by HC Vault possibly with a valid jwt token generated
-// by RegisterServiceAccountHandler.
+// by RegisterServiceAccountHandler. The token is verified in full (signature, issuer, expiry) rather than
+// looked up by its raw string, so a tampered or expired token is rejected the same way kube-apiserver's
+// TokenReview endpoint would reject it.
 func (s *KubeHandler) LoginHandler(w http.ResponseWriter, r *http.Request) {
 	s.logger.Debug("Kube auth server received login request")
 
@@ -172,33 +457,141 @@ func (s *KubeHandler) LoginHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-
-	sa, jwtValid := s.jwtToServiceAccountInfo[req.Spec.Token]
-	if !jwtValid {
-		s.logger.Debug("Received kube login request with unknown token")
+	sa, claims, err := s.authenticateBearerToken(req.Spec.Token)
+	if err != nil {
+		s.logger.WithError(err).Debug("Received kube login request with an invalid token")
 		s.writeResponse(w, http.StatusOK, map[string]any{
+			"apiVersion": "authentication.k8s.io/v1",
+			"kind":       "TokenReview",
+			"spec":       req.Spec,
 			"status": map[string]any{
 				"authenticated": false,
+				"error":         err.Error(),
 			},
 		})
 		return
 	}
 
-	s.writeResponse(w, http.StatusOK, map[string]any{
-		"status": map[string]any{
-			"authenticated": true,
-			"user": map[string]any{
-				"username": fmt.Sprintf("system:serviceaccount:%s:%s", sa.Namespace, sa.Name),
-				"uid":      sa.UID,
+	tokenAudiences, err := claims.GetAudience()
+	if err != nil {
+		s.logger.WithError(err).Debug("Received kube login request with a token missing a valid audience claim")
+		s.writeResponse(w, http.StatusOK, map[string]any{
+			"apiVersion": "authentication.k8s.io/v1",
+			"kind":       "TokenReview",
+			"spec":       req.Spec,
+			"status": map[string]any{
+				"authenticated": false,
+				"error":         fmt.Sprintf("token audience claim: %v", err),
 			},
+		})
+		return
+	}
+
+	var audiences []string
+	if len(req.Spec.Audiences) > 0 {
+		audiences = intersectAudiences(tokenAudiences, req.Spec.Audiences)
+		if len(audiences) == 0 {
+			s.logger.WithField("service_account", sa).Debug("Token audience does not match requested audiences")
+			s.writeResponse(w, http.StatusOK, map[string]any{
+				"apiVersion": "authentication.k8s.io/v1",
+				"kind":       "TokenReview",
+				"spec":       req.Spec,
+				"status": map[string]any{
+					"authenticated": false,
+					"error":         "token is not valid for any of the requested audiences",
+				},
+			})
+			return
+		}
+	}
+
+	groups := sa.Groups
+	if len(groups) == 0 {
+		groups = []string{
+			"system:serviceaccounts",
+			fmt.Sprintf("system:serviceaccounts:%s", sa.Namespace),
+			"system:authenticated",
+		}
+	}
+
+	extra := sa.Extra
+	if extra == nil {
+		extra = map[string][]string{}
+	}
+
+	status := map[string]any{
+		"authenticated": true,
+		"user": map[string]any{
+			"username": fmt.Sprintf("system:serviceaccount:%s:%s", sa.Namespace, sa.Name),
+			"uid":      sa.UID,
+			"groups":   groups,
+			"extra":    extra,
 		},
+	}
+	if len(audiences) > 0 {
+		status["audiences"] = audiences
+	}
+
+	s.writeResponse(w, http.StatusOK, map[string]any{
+		"apiVersion": "authentication.k8s.io/v1",
+		"kind":       "TokenReview",
+		"spec":       req.Spec,
+		"status":     status,
 	})
 
 	s.logger.Debug("Successfully handled kube login request")
 }
 
+// OIDCDiscoveryHandler serves the OIDC discovery document for this mock's issuer, mirroring kube-apiserver's
+// /.well-known/openid-configuration endpoint so that OIDC-validating clients can find the JWKS.
+func (s *KubeHandler) OIDCDiscoveryHandler(w http.ResponseWriter, r *http.Request) {
+	s.logger.Debug("Kube auth server received OIDC discovery request")
+
+	if r.Method != http.MethodGet {
+		s.writeResponse(w, http.StatusNotImplemented, map[string]any{
+			"success": false,
+			"error":   fmt.Sprintf("OIDC discovery handler expects GET but got %q", r.Method),
+		})
+		return
+	}
+
+	s.writeResponse(w, http.StatusOK, map[string]any{
+		"issuer":                                s.issuer,
+		"jwks_uri":                              s.issuer + "/openid/v1/jwks",
+		"response_types_supported":              []string{"id_token"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+	})
+}
+
+// JWKSHandler serves the handler's public signing key as a JWKS, mirroring kube-apiserver's
+// /openid/v1/jwks endpoint, so that third parties can verify tokens minted by this mock.
+func (s *KubeHandler) JWKSHandler(w http.ResponseWriter, r *http.Request) {
+	s.logger.Debug("Kube auth server received JWKS request")
+
+	if r.Method != http.MethodGet {
+		s.writeResponse(w, http.StatusNotImplemented, map[string]any{
+			"success": false,
+			"error":   fmt.Sprintf("JWKS handler expects GET but got %q", r.Method),
+		})
+		return
+	}
+
+	pub := s.signingKey.PublicKey
+	s.writeResponse(w, http.StatusOK, map[string]any{
+		"keys": []map[string]any{
+			{
+				"kty": "RSA",
+				"kid": s.keyID,
+				"alg": "RS256",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+			},
+		},
+	})
+}
+
 func (s *KubeHandler) writeResponse(w http.ResponseWriter, statusCode int, resp any) {
 	w.WriteHeader(statusCode)
 	if resp == nil {
@@ -214,24 +607,117 @@ func (s *KubeHandler) writeResponse(w http.ResponseWriter, statusCode int, resp
 	}
 }
 
-// generateKubeJWT generates a valid k8s jwt token that the vault testing instance can accept and validate.
-func generateKubeJWT(service, namespace, uid string) (string, error) {
-	secretKey, err := rsa.GenerateKey(rand.Reader, 2048)
-	if err != nil {
-		return "", fmt.Errorf("generate secret key: %w", err)
+// kubeJWTBoundObjectRef mirrors the object a projected token is bound to, e.g. the pod it was mounted into.
+type kubeJWTBoundObjectRef struct {
+	Kind string
+	Name string
+	UID  string
+}
+
+// kubeJWTClaims carries everything generateKubeJWT needs to mint a projected service account token.
+type kubeJWTClaims struct {
+	Name              string
+	Namespace         string
+	UID               string
+	Audiences         []string
+	ExpirationSeconds int64
+	BoundObjectRef    *kubeJWTBoundObjectRef
+	// ForceInvalid is a testing-only knob: one of the forceInvalid* constants deliberately breaks the
+	// minted token so callers can exercise Vault's error-handling branches.
+	ForceInvalid string
+}
+
+// generateKubeJWT generates a valid k8s projected service account jwt token, signed with the handler's
+// persistent signing key, that the vault testing instance can accept and validate. If claims.ForceInvalid
+// is set, the token is instead deliberately broken in the requested way.
+func (s *KubeHandler) generateKubeJWT(claims kubeJWTClaims) (signedJWT string, expiresAt time.Time, err error) {
+	if len(claims.Audiences) == 0 {
+		claims.Audiences = []string{defaultServiceAccountAudience}
+	}
+	if claims.ExpirationSeconds <= 0 {
+		claims.ExpirationSeconds = defaultTokenExpirationSeconds
 	}
 
-	claims := jwt.MapClaims{
-		"kubernetes.io/serviceaccount/service-account.uid":  uid,
-		"kubernetes.io/serviceaccount/service-account.name": service,
-		"kubernetes.io/serviceaccount/namespace":            namespace,
+	now := time.Now()
+	expiresAt = now.Add(time.Duration(claims.ExpirationSeconds) * time.Second)
+
+	audiences := claims.Audiences
+	if claims.ForceInvalid == forceInvalidWrongAudience {
+		audiences = []string{"https://wrong-audience.example.com"}
+	}
+
+	exp := expiresAt
+	if claims.ForceInvalid == forceInvalidExpired {
+		exp = now.Add(-time.Hour)
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
-	signedJWT, err := token.SignedString(secretKey)
+	kubernetesClaims := map[string]any{
+		"namespace": claims.Namespace,
+		"serviceaccount": map[string]any{
+			"name": claims.Name,
+			"uid":  claims.UID,
+		},
+	}
+	if ref := claims.BoundObjectRef; ref != nil {
+		kubernetesClaims[strings.ToLower(ref.Kind)] = map[string]any{
+			"name": ref.Name,
+			"uid":  ref.UID,
+		}
+	}
+
+	mapClaims := jwt.MapClaims{
+		"iss":                  s.issuer,
+		"sub":                  fmt.Sprintf("system:serviceaccount:%s:%s", claims.Namespace, claims.Name),
+		"aud":                  audiences,
+		"exp":                  exp.Unix(),
+		"iat":                  now.Unix(),
+		serviceAccountUIDClaim: claims.UID,
+		"kubernetes.io/serviceaccount/service-account.name": claims.Name,
+		"kubernetes.io/serviceaccount/namespace":            claims.Namespace,
+		"kubernetes.io":                                     kubernetesClaims,
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, mapClaims)
+	token.Header["kid"] = s.keyID
+
+	signingKey := s.signingKey
+	if claims.ForceInvalid == forceInvalidBadSignature {
+		signingKey, err = rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return "", time.Time{}, fmt.Errorf("generate bad signing key: %w", err)
+		}
+	}
+
+	signedJWT, err = token.SignedString(signingKey)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("sign token: %w", err)
+	}
+
+	return signedJWT, expiresAt, nil
+}
+
+// newUID generates a random identifier in the shape kube-apiserver uses for object UIDs.
+func newUID() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
 	if err != nil {
-		return "", fmt.Errorf("sign token: %w", err)
+		return "", fmt.Errorf("read random bytes: %w", err)
 	}
+	return hex.EncodeToString(b), nil
+}
 
-	return signedJWT, nil
+// intersectAudiences returns the subset of want that is also present in have, preserving want's order.
+func intersectAudiences(have, want []string) []string {
+	haveSet := make(map[string]struct{}, len(have))
+	for _, aud := range have {
+		haveSet[aud] = struct{}{}
+	}
+
+	var matched []string
+	for _, aud := range want {
+		if _, ok := haveSet[aud]; ok {
+			matched = append(matched, aud)
+		}
+	}
+	return matched
 }
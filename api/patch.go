@@ -0,0 +1,219 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// applyMergePatch applies an RFC 7396 JSON merge patch on top of existing. Strategic merge patches are
+// handled the same way here since this mock doesn't need to honor the real strategic-merge-patch schema
+// annotations (patchMergeKey/patchStrategy) to be useful to callers that just PATCH a field or two.
+func applyMergePatch(existing map[string]any, patch []byte) (map[string]any, error) {
+	var patchObj map[string]any
+	err := json.Unmarshal(patch, &patchObj)
+	if err != nil {
+		return nil, fmt.Errorf("decode merge patch: %w", err)
+	}
+
+	merged, ok := mergeJSON(deepCopyJSON(existing), patchObj).(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("merge patch produced a non-object document")
+	}
+	return merged, nil
+}
+
+func mergeJSON(existing, patch any) any {
+	patchMap, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+
+	existingMap, ok := existing.(map[string]any)
+	if !ok {
+		existingMap = make(map[string]any)
+	}
+
+	merged := make(map[string]any, len(existingMap))
+	for k, v := range existingMap {
+		merged[k] = v
+	}
+
+	for k, v := range patchMap {
+		if v == nil {
+			delete(merged, k)
+			continue
+		}
+		merged[k] = mergeJSON(merged[k], v)
+	}
+
+	return merged
+}
+
+// jsonPatchOp is a single RFC 6902 JSON Patch operation.
+type jsonPatchOp struct {
+	Op    string `json:"op"`
+	Path  string `json:"path"`
+	Value any    `json:"value"`
+}
+
+// applyJSONPatch applies an RFC 6902 JSON Patch document on top of existing, supporting the add, replace,
+// and remove operations controllers typically use against this mock. add and replace are applied the same
+// way, so an add at an existing array index overwrites that element rather than inserting before it; only an
+// index equal to the array's length (or "-") appends. existing is left untouched: the ops
+// run against a deep copy, since setJSONPointer/removeJSONPointer mutate the containers they descend into
+// in place, and existing may still be the live object another caller holds a reference to.
+func applyJSONPatch(existing map[string]any, patch []byte) (map[string]any, error) {
+	var ops []jsonPatchOp
+	err := json.Unmarshal(patch, &ops)
+	if err != nil {
+		return nil, fmt.Errorf("decode json patch: %w", err)
+	}
+
+	doc := deepCopyJSON(existing)
+	for _, op := range ops {
+		pointer := jsonPointerSegments(op.Path)
+
+		var err error
+		switch op.Op {
+		case "add", "replace":
+			doc, err = setJSONPointer(doc, pointer, op.Value)
+		case "remove":
+			doc, err = removeJSONPointer(doc, pointer)
+		default:
+			return nil, fmt.Errorf("unsupported json patch op %q", op.Op)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("apply json patch op %q at %q: %w", op.Op, op.Path, err)
+		}
+	}
+
+	result, ok := doc.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("json patch produced a non-object document")
+	}
+	return result, nil
+}
+
+func jsonPointerSegments(path string) []string {
+	segments := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	for i, segment := range segments {
+		segment = strings.ReplaceAll(segment, "~1", "/")
+		segment = strings.ReplaceAll(segment, "~0", "~")
+		segments[i] = segment
+	}
+	return segments
+}
+
+func setJSONPointer(doc any, pointer []string, value any) (any, error) {
+	if len(pointer) == 0 {
+		return value, nil
+	}
+
+	key := pointer[0]
+	switch d := doc.(type) {
+	case map[string]any:
+		if len(pointer) == 1 {
+			d[key] = value
+			return d, nil
+		}
+		updated, err := setJSONPointer(d[key], pointer[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		d[key] = updated
+		return d, nil
+	case []any:
+		index, err := jsonPointerIndex(key, len(d))
+		if err != nil {
+			return nil, err
+		}
+		if len(pointer) == 1 {
+			if index == len(d) {
+				return append(d, value), nil
+			}
+			d[index] = value
+			return d, nil
+		}
+		updated, err := setJSONPointer(d[index], pointer[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		d[index] = updated
+		return d, nil
+	default:
+		return nil, fmt.Errorf("cannot descend into non-container value at %q", key)
+	}
+}
+
+func removeJSONPointer(doc any, pointer []string) (any, error) {
+	if len(pointer) == 0 {
+		return nil, fmt.Errorf("empty remove path")
+	}
+
+	key := pointer[0]
+	switch d := doc.(type) {
+	case map[string]any:
+		if len(pointer) == 1 {
+			delete(d, key)
+			return d, nil
+		}
+		updated, err := removeJSONPointer(d[key], pointer[1:])
+		if err != nil {
+			return nil, err
+		}
+		d[key] = updated
+		return d, nil
+	case []any:
+		index, err := jsonPointerIndex(key, len(d))
+		if err != nil {
+			return nil, err
+		}
+		if len(pointer) == 1 {
+			return append(d[:index], d[index+1:]...), nil
+		}
+		updated, err := removeJSONPointer(d[index], pointer[1:])
+		if err != nil {
+			return nil, err
+		}
+		d[index] = updated
+		return d, nil
+	default:
+		return nil, fmt.Errorf("cannot descend into non-container value at %q", key)
+	}
+}
+
+func jsonPointerIndex(segment string, length int) (int, error) {
+	if segment == "-" {
+		return length, nil
+	}
+
+	index, err := strconv.Atoi(segment)
+	if err != nil || index < 0 || index > length {
+		return 0, fmt.Errorf("invalid array index %q", segment)
+	}
+	return index, nil
+}
+
+// deepCopyJSON recursively copies a value produced by encoding/json.Unmarshal into map[string]any (maps,
+// slices, and scalars), so callers can mutate the copy without the change being visible through any other
+// reference to the original.
+func deepCopyJSON(v any) any {
+	switch val := v.(type) {
+	case map[string]any:
+		cp := make(map[string]any, len(val))
+		for k, elem := range val {
+			cp[k] = deepCopyJSON(elem)
+		}
+		return cp
+	case []any:
+		cp := make([]any, len(val))
+		for i, elem := range val {
+			cp[i] = deepCopyJSON(elem)
+		}
+		return cp
+	default:
+		return val
+	}
+}
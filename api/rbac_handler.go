@@ -0,0 +1,193 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// rbacRuleSubject identifies the service account an rbacRule applies to.
+type rbacRuleSubject struct {
+	Namespace      string `json:"namespace"`
+	ServiceAccount string `json:"serviceaccount"`
+}
+
+// rbacAllowedRule describes a set of actions a subject is allowed to perform. An empty Namespaces, Names,
+// or Groups list means "any", matching how kube RBAC rules without a restriction apply everywhere.
+type rbacAllowedRule struct {
+	Verbs      []string `json:"verbs"`
+	Resources  []string `json:"resources"`
+	Namespaces []string `json:"namespaces,omitempty"`
+	Names      []string `json:"names,omitempty"`
+	Groups     []string `json:"groups,omitempty"`
+}
+
+// rbacRule binds a subject to the set of actions it's allowed to perform. Tests register these via
+// RBACTestHandler and AccessReviewHandler evaluates SelfSubjectAccessReview/SubjectAccessReview requests
+// against them.
+type rbacRule struct {
+	Subject rbacRuleSubject   `json:"subject"`
+	Allowed []rbacAllowedRule `json:"allowed"`
+}
+
+// rbacTestRequest is the body accepted by RBACTestHandler.
+type rbacTestRequest struct {
+	Rules []rbacRule `json:"rules"`
+}
+
+// resourceAttributes mirrors authorization.k8s.io/v1 ResourceAttributes.
+type resourceAttributes struct {
+	Namespace string `json:"namespace"`
+	Verb      string `json:"verb"`
+	Resource  string `json:"resource"`
+	Name      string `json:"name"`
+	Group     string `json:"group"`
+}
+
+// accessReviewRequest mirrors the parts of SelfSubjectAccessReview/SubjectAccessReview that
+// AccessReviewHandler cares about.
+type accessReviewRequest struct {
+	Spec struct {
+		ResourceAttributes resourceAttributes `json:"resourceAttributes"`
+	} `json:"spec"`
+}
+
+// RBACTestHandler lets unit tests register the RBAC rules AccessReviewHandler evaluates against. Rules
+// accumulate across calls and are wiped by a full ResetHandler call.
+func (s *KubeHandler) RBACTestHandler(w http.ResponseWriter, r *http.Request) {
+	s.logger.Debug("Kube auth server received RBAC rule registration request")
+
+	if r.Method != http.MethodPost {
+		s.writeResponse(w, http.StatusNotImplemented, map[string]any{
+			"success": false,
+			"error":   fmt.Sprintf("RBAC rule registration handler expects POST but got %q", r.Method),
+		})
+		return
+	}
+
+	var req rbacTestRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		s.logger.WithError(err).Error("Could not decode RBAC rule registration request")
+		s.writeResponse(w, http.StatusBadRequest, map[string]any{
+			"success": false,
+			"error":   fmt.Sprintf("invalid RBAC rule registration request: %v", err),
+		})
+		return
+	}
+
+	s.rbacMu.Lock()
+	s.rbacRules = append(s.rbacRules, req.Rules...)
+	s.rbacMu.Unlock()
+
+	s.writeResponse(w, http.StatusOK, map[string]any{
+		"success": true,
+	})
+}
+
+// AccessReviewHandler handles SelfSubjectAccessReview and SubjectAccessReview requests. It identifies the
+// caller from its bearer token, the same way LoginHandler does, and evaluates spec.resourceAttributes
+// against the rules registered via RBACTestHandler.
+func (s *KubeHandler) AccessReviewHandler(w http.ResponseWriter, r *http.Request) {
+	s.logger.Debug("Kube auth server received access review request")
+
+	if r.Method != http.MethodPost {
+		s.writeResponse(w, http.StatusNotImplemented, map[string]any{
+			"success": false,
+			"error":   fmt.Sprintf("access review handler expects POST but got %q", r.Method),
+		})
+		return
+	}
+
+	var req accessReviewRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		s.logger.WithError(err).Error("Could not decode access review request")
+		s.writeResponse(w, http.StatusBadRequest, map[string]any{
+			"success": false,
+			"error":   fmt.Sprintf("invalid access review request: %v", err),
+		})
+		return
+	}
+
+	token := bearerToken(r.Header.Get("Authorization"))
+
+	sa, _, err := s.authenticateBearerToken(token)
+	if err != nil {
+		s.logger.WithError(err).Debug("Received access review request with an invalid bearer token")
+		s.writeResponse(w, http.StatusOK, map[string]any{
+			"status": map[string]any{
+				"allowed": false,
+				"reason":  err.Error(),
+			},
+		})
+		return
+	}
+
+	s.rbacMu.RLock()
+	allowed, reason := evaluateRBAC(s.rbacRules, sa, req.Spec.ResourceAttributes)
+	s.rbacMu.RUnlock()
+
+	s.writeResponse(w, http.StatusOK, map[string]any{
+		"status": map[string]any{
+			"allowed": allowed,
+			"reason":  reason,
+		},
+	})
+
+	s.logger.Debug("Successfully handled access review request")
+}
+
+// bearerToken extracts the token from a "Bearer <token>" Authorization header, returning "" if the header
+// isn't in that form.
+func bearerToken(header string) string {
+	const prefix = "Bearer "
+	if !strings.HasPrefix(header, prefix) {
+		return ""
+	}
+	return strings.TrimPrefix(header, prefix)
+}
+
+// evaluateRBAC reports whether any rule registered for sa allows the requested resourceAttributes.
+func evaluateRBAC(rules []rbacRule, sa serviceAccountInfo, attrs resourceAttributes) (allowed bool, reason string) {
+	for _, rule := range rules {
+		if rule.Subject.Namespace != sa.Namespace || rule.Subject.ServiceAccount != sa.Name {
+			continue
+		}
+
+		for _, allowedRule := range rule.Allowed {
+			if rbacRuleMatches(allowedRule, attrs) {
+				return true, fmt.Sprintf("allowed by registered RBAC rule for %s/%s", sa.Namespace, sa.Name)
+			}
+		}
+	}
+
+	return false, fmt.Sprintf("no RBAC rule allows verb %q on resource %q for %s/%s", attrs.Verb, attrs.Resource, sa.Namespace, sa.Name)
+}
+
+// rbacRuleMatches reports whether allowed covers attrs. A "*" entry matches anything, and an empty
+// Namespaces or Names list is treated as unrestricted.
+func rbacRuleMatches(allowed rbacAllowedRule, attrs resourceAttributes) bool {
+	if !rbacFieldMatches(allowed.Verbs, attrs.Verb) {
+		return false
+	}
+	if !rbacFieldMatches(allowed.Resources, attrs.Resource) {
+		return false
+	}
+	if len(allowed.Namespaces) > 0 && !rbacFieldMatches(allowed.Namespaces, attrs.Namespace) {
+		return false
+	}
+	if len(allowed.Names) > 0 && !rbacFieldMatches(allowed.Names, attrs.Name) {
+		return false
+	}
+	if len(allowed.Groups) > 0 && !rbacFieldMatches(allowed.Groups, attrs.Group) {
+		return false
+	}
+	return true
+}
+
+func rbacFieldMatches(values []string, want string) bool {
+	return slices.Contains(values, "*") || slices.Contains(values, want)
+}
@@ -0,0 +1,234 @@
+package api
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+)
+
+var (
+	errResourceExists   = errors.New("resource already exists")
+	errResourceNotFound = errors.New("resource not found")
+)
+
+// watchEventType mirrors the "type" field of a kube watch event.
+type watchEventType string
+
+const (
+	watchEventAdded    watchEventType = "ADDED"
+	watchEventModified watchEventType = "MODIFIED"
+	watchEventDeleted  watchEventType = "DELETED"
+)
+
+// watchEvent is a single event streamed to watchers of a genericResourceStore.
+type watchEvent struct {
+	Type   watchEventType `json:"type"`
+	Object map[string]any `json:"object"`
+}
+
+// genericResourceStore is an in-memory, namespace-scoped store for a single kube resource kind (Secrets,
+// ConfigMaps, ServiceAccounts, ...) — enough to back controllers that GET/POST/PATCH/DELETE or watch a
+// resource via the kube API, without reimplementing kube-apiserver.
+type genericResourceStore struct {
+	mu      sync.RWMutex
+	objects map[string]map[string]map[string]any // namespace -> name -> object
+
+	watchMu  sync.Mutex
+	watchers map[string][]chan watchEvent // namespace -> subscriber channels
+}
+
+func newGenericResourceStore() *genericResourceStore {
+	return &genericResourceStore{
+		objects:  make(map[string]map[string]map[string]any),
+		watchers: make(map[string][]chan watchEvent),
+	}
+}
+
+// reset wipes every object in the store. Watchers are left in place so an in-flight watch doesn't break.
+func (g *genericResourceStore) reset() {
+	g.mu.Lock()
+	g.objects = make(map[string]map[string]map[string]any)
+	g.mu.Unlock()
+}
+
+func (g *genericResourceStore) list(namespace string) []map[string]any {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	objs := make([]map[string]any, 0, len(g.objects[namespace]))
+	for _, obj := range g.objects[namespace] {
+		objs = append(objs, snapshot(obj))
+	}
+	return objs
+}
+
+func (g *genericResourceStore) get(namespace, name string) (map[string]any, bool) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	obj, ok := g.objects[namespace][name]
+	if !ok {
+		return nil, false
+	}
+	return snapshot(obj), true
+}
+
+func (g *genericResourceStore) create(namespace, name string, obj map[string]any) (map[string]any, error) {
+	g.mu.Lock()
+	if g.objects[namespace] == nil {
+		g.objects[namespace] = make(map[string]map[string]any)
+	}
+	if _, exists := g.objects[namespace][name]; exists {
+		g.mu.Unlock()
+		return nil, errResourceExists
+	}
+
+	obj = withMetadata(obj, namespace, name, "1")
+	g.objects[namespace][name] = obj
+	g.mu.Unlock()
+
+	out := snapshot(obj)
+	g.broadcast(namespace, watchEvent{Type: watchEventAdded, Object: out})
+	return out, nil
+}
+
+func (g *genericResourceStore) update(namespace, name string, obj map[string]any) (map[string]any, error) {
+	g.mu.Lock()
+	existing, exists := g.objects[namespace][name]
+	if !exists {
+		g.mu.Unlock()
+		return nil, errResourceNotFound
+	}
+
+	obj = withMetadata(obj, namespace, name, nextResourceVersion(existing))
+	g.objects[namespace][name] = obj
+	g.mu.Unlock()
+
+	out := snapshot(obj)
+	g.broadcast(namespace, watchEvent{Type: watchEventModified, Object: out})
+	return out, nil
+}
+
+// patch looks up the existing object, runs apply on it, stamps a bumped resourceVersion onto the result,
+// and stores it. If no object exists under namespace/name, patch returns errResourceNotFound without calling
+// apply. apply is expected to return a value independent of existing (applyJSONPatch and applyMergePatch both do),
+// since existing is the live stored object and must not be mutated in place.
+func (g *genericResourceStore) patch(namespace, name string, apply func(existing map[string]any) (map[string]any, error)) (map[string]any, error) {
+	g.mu.Lock()
+	existing, exists := g.objects[namespace][name]
+	if !exists {
+		g.mu.Unlock()
+		return nil, errResourceNotFound
+	}
+
+	patched, err := apply(existing)
+	if err != nil {
+		g.mu.Unlock()
+		return nil, err
+	}
+
+	patched = withMetadata(patched, namespace, name, nextResourceVersion(existing))
+	g.objects[namespace][name] = patched
+	g.mu.Unlock()
+
+	out := snapshot(patched)
+	g.broadcast(namespace, watchEvent{Type: watchEventModified, Object: out})
+	return out, nil
+}
+
+func (g *genericResourceStore) delete(namespace, name string) (map[string]any, error) {
+	g.mu.Lock()
+	existing, exists := g.objects[namespace][name]
+	if !exists {
+		g.mu.Unlock()
+		return nil, errResourceNotFound
+	}
+	delete(g.objects[namespace], name)
+	g.mu.Unlock()
+
+	out := snapshot(existing)
+	g.broadcast(namespace, watchEvent{Type: watchEventDeleted, Object: out})
+	return out, nil
+}
+
+// watch subscribes to ADDED/MODIFIED/DELETED events for namespace. The returned cancel func must be called
+// once the caller is done watching, to unregister and close the channel.
+func (g *genericResourceStore) watch(namespace string) (events <-chan watchEvent, cancel func()) {
+	ch := make(chan watchEvent, 16)
+
+	g.watchMu.Lock()
+	g.watchers[namespace] = append(g.watchers[namespace], ch)
+	g.watchMu.Unlock()
+
+	cancel = func() {
+		g.watchMu.Lock()
+		defer g.watchMu.Unlock()
+
+		subs := g.watchers[namespace]
+		for i, sub := range subs {
+			if sub == ch {
+				g.watchers[namespace] = append(subs[:i], subs[i+1:]...)
+				break
+			}
+		}
+		close(ch)
+	}
+
+	return ch, cancel
+}
+
+func (g *genericResourceStore) broadcast(namespace string, event watchEvent) {
+	g.watchMu.Lock()
+	defer g.watchMu.Unlock()
+
+	for _, ch := range g.watchers[namespace] {
+		select {
+		case ch <- event:
+		default:
+			// slow watcher; drop the event rather than block the writer
+		}
+	}
+}
+
+// snapshot deep-copies obj so it's safe to hand to a caller, a watch event, or an in-flight JSON encoding
+// without the store's subsequent in-place edits (withMetadata, a later patch) reaching back into it.
+func snapshot(obj map[string]any) map[string]any {
+	return deepCopyJSON(obj).(map[string]any)
+}
+
+// withMetadata stamps namespace, name, and resourceVersion onto obj's metadata, creating the metadata map
+// if necessary.
+func withMetadata(obj map[string]any, namespace, name, resourceVersion string) map[string]any {
+	metadata, _ := obj["metadata"].(map[string]any)
+	if metadata == nil {
+		metadata = make(map[string]any)
+	}
+	metadata["namespace"] = namespace
+	metadata["name"] = name
+	metadata["resourceVersion"] = resourceVersion
+	obj["metadata"] = metadata
+	return obj
+}
+
+// nextResourceVersion bumps the resourceVersion of an existing object, defaulting to "1" if it's missing
+// or not a recognizable integer.
+func nextResourceVersion(existing map[string]any) string {
+	metadata, _ := existing["metadata"].(map[string]any)
+	current, _ := metadata["resourceVersion"].(string)
+
+	n, err := strconv.Atoi(current)
+	if err != nil {
+		return "1"
+	}
+	return strconv.Itoa(n + 1)
+}
+
+// objectName extracts metadata.name from a decoded resource object.
+func objectName(obj map[string]any) (string, bool) {
+	metadata, ok := obj["metadata"].(map[string]any)
+	if !ok {
+		return "", false
+	}
+	name, ok := metadata["name"].(string)
+	return name, ok
+}
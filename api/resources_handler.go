@@ -0,0 +1,253 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// genericResourceListKinds maps a supported resource name to the "kind" its list response should carry.
+var genericResourceListKinds = map[string]string{
+	"secrets":         "SecretList",
+	"configmaps":      "ConfigMapList",
+	"serviceaccounts": "ServiceAccountList",
+}
+
+// resourceStoreFor returns the genericResourceStore backing resource, if it's one this mock supports.
+func (s *KubeHandler) resourceStoreFor(resource string) (*genericResourceStore, bool) {
+	store, ok := s.resourceStores[resource]
+	return store, ok
+}
+
+// GenericResourceCollectionHandler handles GET (list, or watch when ?watch=true) and POST (create) against
+// /api/v1/namespaces/{namespace}/{resource}, backing a minimal in-memory fake of the kube API for Secrets,
+// ConfigMaps, and ServiceAccounts.
+func (s *KubeHandler) GenericResourceCollectionHandler(w http.ResponseWriter, r *http.Request) {
+	s.logger.WithField("request_url", r.URL).Debug("Kube auth server received generic resource collection request")
+
+	resource := r.PathValue("resource")
+	store, ok := s.resourceStoreFor(resource)
+	if !ok {
+		s.writeResponse(w, http.StatusNotFound, map[string]any{
+			"success": false,
+			"error":   fmt.Sprintf("unsupported resource %q", resource),
+		})
+		return
+	}
+
+	namespace := r.PathValue("namespace")
+
+	switch r.Method {
+	case http.MethodGet:
+		if r.URL.Query().Get("watch") == "true" {
+			s.watchResource(w, r, store, namespace)
+			return
+		}
+
+		s.writeResponse(w, http.StatusOK, map[string]any{
+			"apiVersion": "v1",
+			"kind":       genericResourceListKinds[resource],
+			"items":      store.list(namespace),
+		})
+	case http.MethodPost:
+		var obj map[string]any
+		err := json.NewDecoder(r.Body).Decode(&obj)
+		if err != nil {
+			s.logger.WithError(err).Error("Could not decode resource create request")
+			s.writeResponse(w, http.StatusBadRequest, map[string]any{
+				"success": false,
+				"error":   fmt.Sprintf("invalid resource: %v", err),
+			})
+			return
+		}
+
+		name, _ := objectName(obj)
+		created, err := store.create(namespace, name, obj)
+		switch {
+		case errors.Is(err, errResourceExists):
+			s.writeResponse(w, http.StatusConflict, map[string]any{
+				"success": false,
+				"error":   fmt.Sprintf("%s %q already exists", resource, name),
+			})
+		case err != nil:
+			s.logger.WithError(err).Error("Could not create resource")
+			s.writeResponse(w, http.StatusInternalServerError, map[string]any{
+				"success": false,
+				"error":   fmt.Sprintf("create resource: %v", err),
+			})
+		default:
+			s.writeResponse(w, http.StatusCreated, created)
+		}
+	default:
+		s.writeResponse(w, http.StatusNotImplemented, map[string]any{
+			"success": false,
+			"error":   fmt.Sprintf("resource collection handler expects GET or POST but got %q", r.Method),
+		})
+	}
+}
+
+// GenericResourceItemHandler handles GET, PUT, PATCH, and DELETE against
+// /api/v1/namespaces/{namespace}/{resource}/{name}.
+func (s *KubeHandler) GenericResourceItemHandler(w http.ResponseWriter, r *http.Request) {
+	s.logger.WithField("request_url", r.URL).Debug("Kube auth server received generic resource item request")
+
+	resource := r.PathValue("resource")
+	store, ok := s.resourceStoreFor(resource)
+	if !ok {
+		s.writeResponse(w, http.StatusNotFound, map[string]any{
+			"success": false,
+			"error":   fmt.Sprintf("unsupported resource %q", resource),
+		})
+		return
+	}
+
+	namespace := r.PathValue("namespace")
+	name := r.PathValue("name")
+
+	switch r.Method {
+	case http.MethodGet:
+		obj, found := store.get(namespace, name)
+		if !found {
+			s.writeResponse(w, http.StatusNotFound, map[string]any{
+				"success": false,
+				"error":   fmt.Sprintf("%s %q not found", resource, name),
+			})
+			return
+		}
+		s.writeResponse(w, http.StatusOK, obj)
+	case http.MethodPut:
+		s.putResource(w, r, store, resource, namespace, name)
+	case http.MethodPatch:
+		s.patchResource(w, r, store, resource, namespace, name)
+	case http.MethodDelete:
+		deleted, err := store.delete(namespace, name)
+		switch {
+		case errors.Is(err, errResourceNotFound):
+			s.writeResponse(w, http.StatusNotFound, map[string]any{
+				"success": false,
+				"error":   fmt.Sprintf("%s %q not found", resource, name),
+			})
+		case err != nil:
+			s.logger.WithError(err).Error("Could not delete resource")
+			s.writeResponse(w, http.StatusInternalServerError, map[string]any{
+				"success": false,
+				"error":   fmt.Sprintf("delete resource: %v", err),
+			})
+		default:
+			s.writeResponse(w, http.StatusOK, deleted)
+		}
+	default:
+		s.writeResponse(w, http.StatusNotImplemented, map[string]any{
+			"success": false,
+			"error":   fmt.Sprintf("resource item handler expects GET, PUT, PATCH or DELETE but got %q", r.Method),
+		})
+	}
+}
+
+func (s *KubeHandler) putResource(w http.ResponseWriter, r *http.Request, store *genericResourceStore, resource, namespace, name string) {
+	var obj map[string]any
+	err := json.NewDecoder(r.Body).Decode(&obj)
+	if err != nil {
+		s.logger.WithError(err).Error("Could not decode resource update request")
+		s.writeResponse(w, http.StatusBadRequest, map[string]any{
+			"success": false,
+			"error":   fmt.Sprintf("invalid resource: %v", err),
+		})
+		return
+	}
+
+	updated, err := store.update(namespace, name, obj)
+	switch {
+	case errors.Is(err, errResourceNotFound):
+		s.writeResponse(w, http.StatusNotFound, map[string]any{
+			"success": false,
+			"error":   fmt.Sprintf("%s %q not found", resource, name),
+		})
+	case err != nil:
+		s.logger.WithError(err).Error("Could not update resource")
+		s.writeResponse(w, http.StatusInternalServerError, map[string]any{
+			"success": false,
+			"error":   fmt.Sprintf("update resource: %v", err),
+		})
+	default:
+		s.writeResponse(w, http.StatusOK, updated)
+	}
+}
+
+func (s *KubeHandler) patchResource(w http.ResponseWriter, r *http.Request, store *genericResourceStore, resource, namespace, name string) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		s.logger.WithError(err).Error("Could not read patch request body")
+		s.writeResponse(w, http.StatusBadRequest, map[string]any{
+			"success": false,
+			"error":   fmt.Sprintf("read patch body: %v", err),
+		})
+		return
+	}
+
+	contentType := r.Header.Get("Content-Type")
+
+	updated, err := store.patch(namespace, name, func(existing map[string]any) (map[string]any, error) {
+		if contentType == "application/json-patch+json" {
+			return applyJSONPatch(existing, body)
+		}
+		// application/strategic-merge-patch+json and application/merge-patch+json are both handled as a
+		// plain JSON merge patch; see applyMergePatch's doc comment for why that's good enough here.
+		return applyMergePatch(existing, body)
+	})
+	switch {
+	case errors.Is(err, errResourceNotFound):
+		s.writeResponse(w, http.StatusNotFound, map[string]any{
+			"success": false,
+			"error":   fmt.Sprintf("%s %q not found", resource, name),
+		})
+	case err != nil:
+		s.logger.WithError(err).Error("Could not patch resource")
+		s.writeResponse(w, http.StatusBadRequest, map[string]any{
+			"success": false,
+			"error":   fmt.Sprintf("patch resource: %v", err),
+		})
+	default:
+		s.writeResponse(w, http.StatusOK, updated)
+	}
+}
+
+// watchResource streams ADDED/MODIFIED/DELETED events for namespace as newline-delimited JSON over a
+// chunked response, the way kube-apiserver streams watches.
+func (s *KubeHandler) watchResource(w http.ResponseWriter, r *http.Request, store *genericResourceStore, namespace string) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		s.writeResponse(w, http.StatusInternalServerError, map[string]any{
+			"success": false,
+			"error":   "streaming not supported",
+		})
+		return
+	}
+
+	events, cancel := store.watch(namespace)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	encoder := json.NewEncoder(w)
+	for {
+		select {
+		case event, open := <-events:
+			if !open {
+				return
+			}
+			err := encoder.Encode(event)
+			if err != nil {
+				s.logger.WithError(err).Error("Could not write watch event")
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
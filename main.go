@@ -1,6 +1,7 @@
 package main
 
 import (
+	"crypto/tls"
 	"net"
 	"net/http"
 	"os"
@@ -18,16 +19,38 @@ func main() {
 		logger.SetLevel(logrus.DebugLevel)
 	}
 
-	kubeHandler := api.NewKubeHandler(logger)
+	kubeHandler, err := api.NewKubeHandler(api.ServerOptions{
+		Logger: logger,
+		Issuer: os.Getenv("ISSUER_URL"),
+	})
+	if err != nil {
+		logger.WithError(err).Fatal("Could not create kube handler")
+	}
 	mux := http.NewServeMux()
 	// this is an actual kube endpoint that is called by HC Vault
 	mux.Handle("/apis/authentication.k8s.io/v1/tokenreviews", http.HandlerFunc(kubeHandler.LoginHandler))
+	// this is an actual kube endpoint used to mint fresh, audience-bound service account tokens
+	mux.Handle("/api/v1/namespaces/{namespace}/serviceaccounts/{name}/token", http.HandlerFunc(kubeHandler.TokenHandler))
+	// OIDC discovery and JWKS endpoints so third parties can validate tokens minted by this mock
+	mux.Handle("/.well-known/openid-configuration", http.HandlerFunc(kubeHandler.OIDCDiscoveryHandler))
+	mux.Handle("/openid/v1/jwks", http.HandlerFunc(kubeHandler.JWKSHandler))
+	// these are actual kube endpoints used to probe a caller's own or another subject's permissions
+	mux.Handle("/apis/authorization.k8s.io/v1/selfsubjectaccessreviews", http.HandlerFunc(kubeHandler.AccessReviewHandler))
+	mux.Handle("/apis/authorization.k8s.io/v1/subjectaccessreviews", http.HandlerFunc(kubeHandler.AccessReviewHandler))
+	// these are actual kube endpoints backing an in-memory fake of Secrets, ConfigMaps, and ServiceAccounts
+	mux.Handle("/api/v1/namespaces/{namespace}/{resource}", http.HandlerFunc(kubeHandler.GenericResourceCollectionHandler))
+	mux.Handle("/api/v1/namespaces/{namespace}/{resource}/{name}", http.HandlerFunc(kubeHandler.GenericResourceItemHandler))
 	// this is a custom endpoint that will be called directly by our unit tests to register a fake service account
 	// and generate a valid jwt token for it so that the jwt can later be validated by Vault via the login endpoint above.
 	mux.Handle("/api/v1/testing/serviceaccounts", http.HandlerFunc(kubeHandler.RegisterServiceAccountHandler))
 	mux.Handle("/api/v1/testing/health", http.HandlerFunc(kubeHandler.HealthHandler))
+	// lets unit tests register the RBAC rules AccessReviewHandler evaluates against
+	mux.Handle("/api/v1/testing/rbac", http.HandlerFunc(kubeHandler.RBACTestHandler))
 	// reset endpoint to clean up service account registry before running a test
 	mux.Handle("/api/v1/testing/reset", http.HandlerFunc(kubeHandler.ResetHandler))
+	// lets unit tests fetch the CA this server's certificate was signed by, to configure their kube client
+	// or Vault's kubernetes_ca_cert instead of disabling TLS verification
+	mux.Handle("/api/v1/testing/ca", http.HandlerFunc(kubeHandler.CAHandler))
 	// handle the root endpoint for any unexpected request
 	mux.Handle("/", http.HandlerFunc(kubeHandler.UnimplementedHandler))
 
@@ -36,11 +59,14 @@ func main() {
 	if err != nil {
 		logger.WithField("net_addr", netAddr).WithError(err).Fatal("Could not start tcp listener")
 	}
-	addr := "http://" + netAddr
+	addr := "https://" + netAddr
 	s := http.Server{
 		Handler:     mux,
 		ReadTimeout: 5 * time.Second,
 		Addr:        addr,
+		TLSConfig: &tls.Config{
+			Certificates: []tls.Certificate{kubeHandler.ServerTLSCertificate()},
+		},
 	}
 	defer func() {
 		err := s.Close()
@@ -50,7 +76,7 @@ func main() {
 	}()
 
 	logger.WithField("addr", s.Addr).Info("Starting kube http server")
-	err = s.Serve(ln)
+	err = s.ServeTLS(ln, "", "")
 	if err != nil {
 		logger.WithError(err).Fatal("Kube http server closed with unexpected error")
 	}